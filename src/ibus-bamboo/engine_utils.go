@@ -21,12 +21,14 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/BambooEngine/bamboo-core"
 	"github.com/BambooEngine/goibus/ibus"
+	"github.com/BambooEngine/ibus-bamboo/wm"
 	"github.com/godbus/dbus"
 )
 
@@ -36,8 +38,11 @@ var emojiTrie *TrieNode
 func GetBambooEngineCreator() func(*dbus.Conn, string) dbus.ObjectPath {
 	objectPath := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/IBus/Engine/bamboo/%d", time.Now().UnixNano()))
 	setupConfigDir()
-	go keyPressCapturing()
 	engineName := strings.ToLower(EngineName)
+	if RunRescanProfilesCommand(os.Args[1:], []string{engineName}) {
+		os.Exit(0)
+	}
+	go keyPressCapturing()
 	dictionary = map[string]bool{}
 	emojiTrie = NewTrie()
 
@@ -73,13 +78,18 @@ func (e *IBusBambooEngine) init() {
 	if e.config.IBflags&IBemojiDisabled == 0 && emojiTrie != nil && len(emojiTrie.Children) == 0 {
 		emojiTrie, _ = loadEmojiOne(DictEmojiOne)
 	}
+	if e.config.IBflags&IBsuggestEnabled != 0 && e.suggestEngine == nil {
+		if len(dictionary) == 0 {
+			dictionary, _ = loadDictionary(DictVietnameseCm)
+		}
+		e.suggestEngine = NewSuggestionEngine(dictionary, e.engineName)
+	}
+	if e.hotkeyDispatcher == nil {
+		e.hotkeyDispatcher = newHotkeyDispatcher(e.config.Hotkeys)
+	}
 	keyPressHandler = e.keyPressHandler
 
-	if e.config.IBflags&IBmouseCapturing != 0 {
-		startMouseCapturing()
-	}
-	startMouseRecording()
-	onMouseMove = func() {
+	onMouseMoveFunc := func() {
 		e.Lock()
 		defer e.Unlock()
 		if e.checkInputMode(preeditIM) {
@@ -89,7 +99,7 @@ func (e *IBusBambooEngine) init() {
 			e.commitPreedit(e.getPreeditString())
 		}
 	}
-	onMouseClick = func() {
+	onMouseClickFunc := func() {
 		e.Lock()
 		defer e.Unlock()
 		e.isFirstTimeSendingBS = true
@@ -101,17 +111,24 @@ func (e *IBusBambooEngine) init() {
 			e.keyPressDelay = KEYPRESS_DELAY_MS
 			if e.capabilities&IBUS_CAP_SURROUNDING_TEXT != 0 {
 				//e.ForwardKeyEvent(IBUS_Shift_R, XK_Shift_R-8, 0)
-				x11SendShiftR()
+				wm.Default().SendShiftR()
 				e.isSurroundingTextReady = true
 				e.keyPressDelay = KEYPRESS_DELAY_MS * 10
 			}
 		}
 	}
+	onMouseMove = onMouseMoveFunc
+	onMouseClick = onMouseClickFunc
+	if e.config.IBflags&IBmouseCapturing != 0 {
+		wm.Default().StartMouseCapturing(onMouseClickFunc, onMouseMoveFunc)
+	}
+	startMouseRecording()
 	for i, list := range e.getWhiteList() {
 		for _, wmClasses := range list {
 			e.config.InputModeTable[wmClasses] = i + 1
 		}
 	}
+	e.bootstrapInputModeProfiles()
 	e.config.PreeditWhiteList = nil
 	e.config.SLForwardKeyWhiteList = nil
 	e.config.SurroundingTextWhiteList = nil
@@ -136,6 +153,9 @@ func keyPressCapturing() {
 }
 
 func (e *IBusBambooEngine) resetBuffer() {
+	if e.isSuggestLTOpened {
+		e.closeSuggestionLT()
+	}
 	if e.getRawKeyLen() == 0 {
 		return
 	}
@@ -146,18 +166,70 @@ func (e *IBusBambooEngine) resetBuffer() {
 	}
 }
 
+// processShiftKey used to hardcode the "press-and-release one Shift"
+// toggle gesture. It is called for every key event, same as before. While
+// the suggestion LT is open, a key press it handles (Tab, 1-5) is consumed
+// first and returns true. Otherwise, when IBimQuickSwitchEnabled is set,
+// the event goes through the hotkeyDispatcher: that's the only way a
+// non-Shift chord like Ctrl-Space or Super-Space ever gets a chance to
+// fire. Shift-Shift, Ctrl-Space, Super-Space and a disabled lone-Shift all
+// go through the same path. Apart from the suggestion LT case, true means
+// "this was a Shift key, swallow it".
 func (e *IBusBambooEngine) processShiftKey(keyVal, state uint32) bool {
-	if keyVal == IBUS_Shift_L || keyVal == IBUS_Shift_R {
-		// when press one Shift key
-		if state&IBUS_SHIFT_MASK != 0 && state&IBUS_RELEASE_MASK != 0 &&
-			e.config.IBflags&IBimQuickSwitchEnabled != 0 && !e.lastKeyWithShift {
-			e.englishMode = !e.englishMode
-			notify(e.englishMode)
-			e.resetBuffer()
+	if e.isSuggestLTOpened && state&IBUS_RELEASE_MASK == 0 {
+		if handled, _ := e.ltProcessSuggestionKeyEvent(keyVal, 0, state); handled {
+			return true
 		}
-		return true
 	}
-	return false
+	if e.config.IBflags&IBimQuickSwitchEnabled != 0 {
+		if action, ok := e.hotkeyDispatcher.Dispatch(keyVal, state); ok {
+			e.runHotkeyAction(action)
+		}
+	}
+	isShift := keyVal == IBUS_Shift_L || keyVal == IBUS_Shift_R
+	if !isShift {
+		e.refreshSuggestionLT(state)
+	}
+	return isShift
+}
+
+// refreshSuggestionLT keeps the suggestion LT in sync with the preedit
+// buffer: called after every non-Shift key so the candidate list tracks
+// whatever syllable/word the preeditor is currently holding.
+func (e *IBusBambooEngine) refreshSuggestionLT(state uint32) {
+	if e.config.IBflags&IBsuggestEnabled == 0 || !e.checkInputMode(preeditIM) {
+		return
+	}
+	if state&IBUS_RELEASE_MASK != 0 {
+		return
+	}
+	if e.getRawKeyLen() == 0 {
+		if e.isSuggestLTOpened {
+			e.closeSuggestionLT()
+		}
+		return
+	}
+	e.updateSuggestionLT()
+}
+
+// runHotkeyAction performs the side effect for a dispatched hotkey, lone
+// Shift gestures and chords such as Ctrl-Space and Super-Space alike.
+// processShiftKey is its only caller.
+func (e *IBusBambooEngine) runHotkeyAction(action Action) {
+	switch action {
+	case ActionToggleLang:
+		e.englishMode = !e.englishMode
+		notify(e.englishMode)
+		e.resetBuffer()
+	case ActionOpenInputModeLT:
+		e.openLookupTable()
+	case ActionOpenEmojiLT:
+		e.openEmojiLookupTable()
+	case ActionRestoreLastWord:
+		e.restoreLastWord()
+	case ActionCommitPreedit:
+		e.commitPreedit(e.getPreeditString())
+	}
 }
 
 func (e *IBusBambooEngine) updateLastKeyWithShift(keyVal, state uint32) {
@@ -230,8 +302,94 @@ func (e *IBusBambooEngine) openLookupTable() {
 	e.UpdateLookupTable(lt, true)
 }
 
+// openSuggestionLT shows the top completions of the syllable/word currently
+// held in preedit below the preedit text, the same way ibus-pinyin shows
+// candidates during composition. Reached through updateSuggestionLT from
+// refreshSuggestionLT, which processShiftKey runs after every non-Shift key
+// while the engine is in preeditIM with IBsuggestEnabled set.
+func (e *IBusBambooEngine) openSuggestionLT() {
+	candidates := e.suggestEngine.Suggest(e.preeditor.GetRawString(), suggestPageSize)
+	if len(candidates) == 0 {
+		if e.isSuggestLTOpened {
+			e.closeSuggestionLT()
+		}
+		return
+	}
+	lt := ibus.NewLookupTable()
+	lt.PageSize = uint32(suggestPageSize)
+	lt.Orientation = IBUS_ORIENTATION_VERTICAL
+	for _, c := range candidates {
+		lt.AppendCandidate(c)
+	}
+	e.suggestLookupTable = lt
+	e.suggestCandidates = candidates
+	e.isSuggestLTOpened = true
+	e.UpdateLookupTable(lt, true)
+}
+
+// updateSuggestionLT recomputes candidates as the user keeps typing. It
+// rebuilds the table through openSuggestionLT on every keystroke; the first
+// show and later refreshes take the same path.
+func (e *IBusBambooEngine) updateSuggestionLT() {
+	e.openSuggestionLT()
+}
+
+func (e *IBusBambooEngine) closeSuggestionLT() {
+	e.suggestLookupTable = nil
+	e.suggestCandidates = nil
+	e.isSuggestLTOpened = false
+	e.UpdateLookupTable(ibus.NewLookupTable(), true)
+	e.HideLookupTable()
+}
+
+// ltProcessSuggestionKeyEvent handles keys while the suggestion LT is open:
+// 1-5 commits the matching candidate, Tab cycles the cursor, and anything
+// else falls through to normal preedit processing.
+func (e *IBusBambooEngine) ltProcessSuggestionKeyEvent(keyVal uint32, keyCode uint32, state uint32) (bool, *dbus.Error) {
+	if keyVal == IBUS_Tab {
+		pos := (e.suggestLookupTable.CursorPos + 1) % uint32(len(e.suggestLookupTable.Candidates))
+		e.suggestLookupTable.SetCursorPos(pos)
+		e.UpdateLookupTable(e.suggestLookupTable, true)
+		return true, nil
+	}
+	var keyRune = rune(keyVal)
+	if keyRune >= '1' && keyRune <= '5' {
+		if pos, err := strconv.Atoi(string(keyRune)); err == nil && pos <= len(e.suggestLookupTable.Candidates) {
+			e.suggestLookupTable.SetCursorPos(uint32(pos - 1))
+			e.commitSuggestionCandidate()
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// commitSuggestionCandidate commits the candidate under the cursor and
+// records it in the user-frequency store so it ranks higher next time.
+func (e *IBusBambooEngine) commitSuggestionCandidate() {
+	candidate := e.suggestCandidates[e.suggestLookupTable.CursorPos]
+	e.suggestEngine.Accept(candidate)
+	e.commitText(candidate)
+	e.closeSuggestionLT()
+}
+
+// commitText commits text the same way commitPreedit does for apps that
+// support surrounding text. For apps that don't, it falls back to the
+// clipboard workaround (push the candidate onto the clipboard) instead of
+// ForwardKeyEvent-ing each rune, which some toolkits mangle when it arrives
+// outside of normal key processing, e.g. right after a lookup-table
+// selection.
+func (e *IBusBambooEngine) commitText(text string) {
+	if e.capabilities&IBUS_CAP_SURROUNDING_TEXT != 0 {
+		e.commitPreedit(text)
+		return
+	}
+	if err := wm.Default().SendClipboard(text); err != nil {
+		e.commitPreedit(text)
+	}
+}
+
 func (e *IBusBambooEngine) ltProcessKeyEvent(keyVal uint32, keyCode uint32, state uint32) (bool, *dbus.Error) {
-	var wmClasses = x11GetFocusWindowClass()
+	var wmClasses = wm.Default().FocusedWindowClass()
 	//e.HideLookupTable()
 	fmt.Printf("keyCode 0x%04x keyval 0x%04x | %c\n", keyCode, keyVal, rune(keyVal))
 	//e.HideAuxiliaryText()
@@ -277,7 +435,10 @@ func (e *IBusBambooEngine) ltProcessKeyEvent(keyVal uint32, keyCode uint32, stat
 }
 
 func (e *IBusBambooEngine) commitInputModeCandidate() {
-	var wmClasses = x11GetFocusWindowClass()
+	if e.isSuggestLTOpened {
+		e.closeSuggestionLT()
+	}
+	var wmClasses = wm.Default().FocusedWindowClass()
 	var im = e.inputModeLookupTable.CursorPos + 1
 	e.config.InputModeTable[wmClasses] = int(im)
 
@@ -0,0 +1,164 @@
+/*
+ * Bamboo - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Action names a hotkeyDispatcher can emit. runHotkeyAction switches on
+// these instead of re-deriving intent from raw keyvals every time.
+type Action string
+
+const (
+	ActionToggleLang      Action = "toggle_lang"
+	ActionOpenInputModeLT Action = "open_input_mode_lt"
+	ActionOpenEmojiLT     Action = "open_emoji_lt"
+	ActionRestoreLastWord Action = "restore_last_word"
+	ActionCommitPreedit   Action = "commit_preedit"
+)
+
+// HotkeyBinding maps either a plain modifier+keyval chord, or a "tap"
+// gesture (the same lone modifier pressed and released N times within
+// TapWindowMs, with no other key in between) to an action name.
+//
+// A binding with TapCount == 0 is a plain chord: it fires as soon as
+// KeyVal is pressed with exactly Modifiers held. A binding with
+// TapCount >= 1 is a tap gesture on a lone modifier key (KeyVal is one of
+// IBUS_Shift_L/R, IBUS_Control_L/R, IBUS_Super_L/R): it fires once KeyVal
+// has been pressed-and-released TapCount times in a row, each release
+// landing within TapWindowMs of the previous one, with no other key
+// pressed in between.
+type HotkeyBinding struct {
+	Modifiers   uint32
+	KeyVal      uint32
+	TapCount    int
+	TapWindowMs int
+	Action      Action
+}
+
+// defaultHotkeyBindings are shipped so a fresh config has a sane toggle
+// even before the user opens the settings UI. Shift-Shift takes over from
+// the old lone-Shift toggle; the others exist so users who disable it (it
+// conflicts with GTK Shift-selection and dead keys) still have a way in.
+// Only one TapCount binding is shipped per keyVal: a lone-Shift tap and a
+// Shift-Shift double-tap on the same key can't coexist (the lone-tap would
+// always fire first and the double-tap streak would never be reached), and
+// Dispatch only tracks one streak per binding anyway.
+func defaultHotkeyBindings() []HotkeyBinding {
+	return []HotkeyBinding{
+		{KeyVal: IBUS_Shift_L, TapCount: 2, TapWindowMs: 400, Action: ActionToggleLang},
+		{KeyVal: IBUS_Shift_R, TapCount: 2, TapWindowMs: 400, Action: ActionToggleLang},
+		{Modifiers: IBUS_CONTROL_MASK, KeyVal: IBUS_space, Action: ActionToggleLang},
+		{Modifiers: IBUS_SUPER_MASK, KeyVal: IBUS_space, Action: ActionToggleLang},
+	}
+}
+
+// hotkeyDispatcher replaces the ad-hoc "press-and-release one Shift"
+// gesture in processShiftKey with a table-driven lookup so users can
+// rebind or disable the toggle without a code change.
+type hotkeyDispatcher struct {
+	mu       sync.Mutex
+	bindings []HotkeyBinding
+
+	// tap-gesture bookkeeping, keyed by the binding's index in bindings, not
+	// by KeyVal: two TapCount bindings can share a KeyVal (e.g. a 2-tap and
+	// a 1-tap both on Shift_L), and each needs its own independent streak.
+	lastReleaseAt map[int]time.Time
+	tapStreak     map[int]int
+}
+
+func newHotkeyDispatcher(bindings []HotkeyBinding) *hotkeyDispatcher {
+	if len(bindings) == 0 {
+		bindings = defaultHotkeyBindings()
+	}
+	return &hotkeyDispatcher{
+		bindings:      bindings,
+		lastReleaseAt: map[int]time.Time{},
+		tapStreak:     map[int]int{},
+	}
+}
+
+// Dispatch feeds one raw key event into the dispatcher and returns the
+// action that should fire for it, if any. Non-modifier keys reset the tap
+// streak for every modifier, since a tap gesture requires nothing else be
+// pressed in between.
+func (d *hotkeyDispatcher) Dispatch(keyVal, state uint32) (Action, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	isModifier := isModifierKeyVal(keyVal)
+	isRelease := state&IBUS_RELEASE_MASK != 0
+
+	if !isModifier {
+		if isRelease {
+			return "", false
+		}
+		d.tapStreak = map[int]int{}
+		return d.matchChord(keyVal, state)
+	}
+
+	if !isRelease {
+		return "", false
+	}
+
+	now := time.Now()
+	for i, b := range d.bindings {
+		if b.TapCount == 0 || b.KeyVal != keyVal {
+			continue
+		}
+		last, seen := d.lastReleaseAt[i]
+		window := time.Duration(b.TapWindowMs) * time.Millisecond
+		if seen && now.Sub(last) <= window {
+			d.tapStreak[i]++
+		} else {
+			d.tapStreak[i] = 1
+		}
+		d.lastReleaseAt[i] = now
+		if d.tapStreak[i] == b.TapCount {
+			d.tapStreak[i] = 0
+			return b.Action, true
+		}
+	}
+	return "", false
+}
+
+func (d *hotkeyDispatcher) matchChord(keyVal, state uint32) (Action, bool) {
+	for _, b := range d.bindings {
+		if b.TapCount != 0 {
+			continue
+		}
+		held := state & (IBUS_SHIFT_MASK | IBUS_CONTROL_MASK | IBUS_MOD1_MASK | IBUS_SUPER_MASK)
+		if b.KeyVal == keyVal && held == b.Modifiers {
+			return b.Action, true
+		}
+	}
+	return "", false
+}
+
+func isModifierKeyVal(keyVal uint32) bool {
+	switch keyVal {
+	case IBUS_Shift_L, IBUS_Shift_R, IBUS_Control_L, IBUS_Control_R, IBUS_Super_L, IBUS_Super_R:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+func TestDispatchChordFiresOnlyOnPress(t *testing.T) {
+	d := newHotkeyDispatcher([]HotkeyBinding{
+		{Modifiers: IBUS_CONTROL_MASK, KeyVal: IBUS_space, Action: ActionToggleLang},
+	})
+
+	if action, ok := d.Dispatch(IBUS_space, IBUS_CONTROL_MASK); !ok || action != ActionToggleLang {
+		t.Fatalf("press: got (%q, %v), want (%q, true)", action, ok, ActionToggleLang)
+	}
+	if action, ok := d.Dispatch(IBUS_space, IBUS_CONTROL_MASK|IBUS_RELEASE_MASK); ok {
+		t.Fatalf("release: got (%q, %v), want no action", action, ok)
+	}
+}
+
+func TestDispatchDoubleTap(t *testing.T) {
+	d := newHotkeyDispatcher([]HotkeyBinding{
+		{KeyVal: IBUS_Shift_L, TapCount: 2, TapWindowMs: 400, Action: ActionToggleLang},
+	})
+
+	// First press+release: no action yet.
+	if _, ok := d.Dispatch(IBUS_Shift_L, 0); ok {
+		t.Fatalf("first press should not dispatch")
+	}
+	if _, ok := d.Dispatch(IBUS_Shift_L, IBUS_RELEASE_MASK); ok {
+		t.Fatalf("first release should not complete the double-tap")
+	}
+	// Second press+release within the window: fires.
+	if _, ok := d.Dispatch(IBUS_Shift_L, 0); ok {
+		t.Fatalf("second press should not dispatch")
+	}
+	action, ok := d.Dispatch(IBUS_Shift_L, IBUS_RELEASE_MASK)
+	if !ok || action != ActionToggleLang {
+		t.Fatalf("second release: got (%q, %v), want (%q, true)", action, ok, ActionToggleLang)
+	}
+}
+
+func TestDispatchTapStreakIsPerBinding(t *testing.T) {
+	// A 1-tap and a 2-tap binding on the same KeyVal must not share state:
+	// releasing once should be able to complete the 1-tap binding without
+	// corrupting the 2-tap binding's streak.
+	d := newHotkeyDispatcher([]HotkeyBinding{
+		{KeyVal: IBUS_Shift_L, TapCount: 1, TapWindowMs: 400, Action: ActionRestoreLastWord},
+		{KeyVal: IBUS_Shift_L, TapCount: 2, TapWindowMs: 400, Action: ActionToggleLang},
+	})
+
+	d.Dispatch(IBUS_Shift_L, 0)
+	action, ok := d.Dispatch(IBUS_Shift_L, IBUS_RELEASE_MASK)
+	if !ok || action != ActionRestoreLastWord {
+		t.Fatalf("first release: got (%q, %v), want (%q, true)", action, ok, ActionRestoreLastWord)
+	}
+
+	d.Dispatch(IBUS_Shift_L, 0)
+	action, ok = d.Dispatch(IBUS_Shift_L, IBUS_RELEASE_MASK)
+	if !ok || action != ActionRestoreLastWord {
+		t.Fatalf("second release: got (%q, %v), want (%q, true) again, not a leaked 2-tap streak", action, ok, ActionRestoreLastWord)
+	}
+}
+
+func TestDispatchNonModifierResetsTapStreak(t *testing.T) {
+	d := newHotkeyDispatcher([]HotkeyBinding{
+		{KeyVal: IBUS_Shift_L, TapCount: 2, TapWindowMs: 400, Action: ActionToggleLang},
+	})
+
+	d.Dispatch(IBUS_Shift_L, 0)
+	d.Dispatch(IBUS_Shift_L, IBUS_RELEASE_MASK)
+	// Some unrelated key press in between should reset the streak.
+	d.Dispatch(IBUS_a, 0)
+
+	d.Dispatch(IBUS_Shift_L, 0)
+	if _, ok := d.Dispatch(IBUS_Shift_L, IBUS_RELEASE_MASK); ok {
+		t.Fatalf("streak should have been reset by the intervening key press")
+	}
+}
@@ -0,0 +1,185 @@
+/*
+ * Bamboo - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// currentProfileVersion is bumped whenever the bundled app_profiles.json
+// changes shape in a way that warrants re-running the bootstrap for users
+// who already went through it once (e.g. a saveConfig upgrade).
+const currentProfileVersion = 1
+
+// AppProfilesDB is where the compatibility database installed alongside
+// ibus-bamboo lives. Mirrors DictVietnameseCm/DictEmojiOne, which point at
+// the same share directory.
+const AppProfilesDB = "/usr/share/ibus-bamboo/data/app_profiles.json"
+
+// modeByName resolves the human-readable mode names used in
+// app_profiles.json to the InputModeTable's integer mode values.
+var modeByName = map[string]int{
+	"english": usIM,
+	"preedit": preeditIM,
+}
+
+// bootstrapInputModeProfiles runs once per profile_version bump. It scans
+// every .desktop file ibus-bamboo can find, matches its wm-class against
+// the bundled compatibility database, and seeds InputModeTable so the user
+// never has to open the lookup table for an app that's already known.
+func (e *IBusBambooEngine) bootstrapInputModeProfiles() {
+	if e.config.ProfileVersion >= currentProfileVersion {
+		return
+	}
+	e.applyInputModeProfiles()
+	e.config.ProfileVersion = currentProfileVersion
+	saveConfig(e.config, e.engineName)
+}
+
+// applyInputModeProfiles does the actual scan-and-match; it's split out of
+// bootstrapInputModeProfiles so `--rescan-profiles` can call it without
+// touching ProfileVersion.
+func (e *IBusBambooEngine) applyInputModeProfiles() {
+	profiles, err := loadAppProfilesDB(AppProfilesDB)
+	if err != nil || len(profiles) == 0 {
+		return
+	}
+	for _, wmClass := range installedWMClasses() {
+		modeName, ok := profiles[wmClass]
+		if !ok {
+			continue
+		}
+		mode, ok := modeByName[modeName]
+		if !ok {
+			continue
+		}
+		if _, exists := e.config.InputModeTable[wmClass]; exists {
+			continue
+		}
+		e.config.InputModeTable[wmClass] = mode
+	}
+}
+
+func loadAppProfilesDB(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var db map[string]string
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// installedWMClasses scans $XDG_DATA_DIRS/applications for .desktop files
+// and returns the wm-class each one would show up as, preferring
+// StartupWMClass and falling back to the Exec binary's basename, which is
+// what most toolkits use as the wm-class when the .desktop file doesn't
+// set one explicitly.
+func installedWMClasses() []string {
+	var wmClasses []string
+	for _, dir := range xdgApplicationDirs() {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry.Name(), ".desktop") {
+				continue
+			}
+			if wmClass, ok := parseDesktopWMClass(filepath.Join(dir, entry.Name())); ok {
+				wmClasses = append(wmClasses, wmClass)
+			}
+		}
+	}
+	return wmClasses
+}
+
+func xdgApplicationDirs() []string {
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	var dirs []string
+	for _, dir := range strings.Split(dataDirs, ":") {
+		if dir != "" {
+			dirs = append(dirs, filepath.Join(dir, "applications"))
+		}
+	}
+	return dirs
+}
+
+func parseDesktopWMClass(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	var startupWMClass, exec string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "StartupWMClass="):
+			startupWMClass = strings.TrimPrefix(line, "StartupWMClass=")
+		case strings.HasPrefix(line, "Exec=") && exec == "":
+			exec = strings.TrimPrefix(line, "Exec=")
+		}
+	}
+	if startupWMClass != "" {
+		return strings.ToLower(startupWMClass), true
+	}
+	fields := strings.Fields(exec)
+	if len(fields) == 0 {
+		return "", false
+	}
+	return strings.ToLower(filepath.Base(fields[0])), true
+}
+
+// RunRescanProfilesCommand implements `ibus-bamboo --rescan-profiles`: it
+// re-runs the .desktop scan for each engine in engineNames without requiring
+// a fresh profile_version bump, so users can pick up newly installed apps
+// on demand. Returns true if it recognized and handled the flag.
+func RunRescanProfilesCommand(args []string, engineNames []string) bool {
+	var rescan bool
+	for _, arg := range args {
+		if arg == "--rescan-profiles" {
+			rescan = true
+			break
+		}
+	}
+	if !rescan {
+		return false
+	}
+	for _, engineName := range engineNames {
+		config := loadConfig(engineName)
+		engine := &IBusBambooEngine{engineName: engineName, config: config}
+		engine.applyInputModeProfiles()
+		saveConfig(engine.config, engineName)
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeDesktopFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.desktop")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write test .desktop file: %v", err)
+	}
+	return path
+}
+
+func TestParseDesktopWMClassPrefersStartupWMClass(t *testing.T) {
+	path := writeDesktopFile(t, "[Desktop Entry]\nExec=firefox %u\nStartupWMClass=Firefox\n")
+	got, ok := parseDesktopWMClass(path)
+	if !ok || got != "firefox" {
+		t.Fatalf("got (%q, %v), want (%q, true)", got, ok, "firefox")
+	}
+}
+
+func TestParseDesktopWMClassFallsBackToExecBasename(t *testing.T) {
+	path := writeDesktopFile(t, "[Desktop Entry]\nExec=/usr/bin/code --unity-launch %F\n")
+	got, ok := parseDesktopWMClass(path)
+	if !ok || got != "code" {
+		t.Fatalf("got (%q, %v), want (%q, true)", got, ok, "code")
+	}
+}
+
+func TestParseDesktopWMClassWhitespaceOnlyExec(t *testing.T) {
+	path := writeDesktopFile(t, "[Desktop Entry]\nExec=   \n")
+	got, ok := parseDesktopWMClass(path)
+	if ok {
+		t.Fatalf("got (%q, true), want ok=false for a whitespace-only Exec=", got)
+	}
+}
+
+func TestParseDesktopWMClassNeitherFieldPresent(t *testing.T) {
+	path := writeDesktopFile(t, "[Desktop Entry]\nName=NoExecOrClass\n")
+	got, ok := parseDesktopWMClass(path)
+	if ok {
+		t.Fatalf("got (%q, true), want ok=false when neither field is set", got)
+	}
+}
+
+func TestParseDesktopWMClassMissingFile(t *testing.T) {
+	got, ok := parseDesktopWMClass(filepath.Join(t.TempDir(), "missing.desktop"))
+	if ok {
+		t.Fatalf("got (%q, true), want ok=false for a missing file", got)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+ * Bamboo - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IBsuggestEnabled is the next free IBflags bit; it guards the predictive
+// completion lookup table added alongside preedit.
+const IBsuggestEnabled = 1 << 16
+
+// suggestPageSize mirrors the input-mode lookup table's convention of one
+// page per candidate set, sized to the number keys 1-5 that commit them.
+const suggestPageSize = 5
+
+// suggestNode is a plain prefix-trie node over the loaded Vietnamese
+// dictionary, kept separate from the emoji TrieNode since that one is
+// keyed by emoji shortcodes, not syllables.
+type suggestNode struct {
+	children map[rune]*suggestNode
+	isWord   bool
+}
+
+func newSuggestNode() *suggestNode {
+	return &suggestNode{children: map[rune]*suggestNode{}}
+}
+
+func (n *suggestNode) insert(word string) {
+	cur := n
+	for _, r := range word {
+		next, ok := cur.children[r]
+		if !ok {
+			next = newSuggestNode()
+			cur.children[r] = next
+		}
+		cur = next
+	}
+	cur.isWord = true
+}
+
+// collect walks every word reachable below the node reached by prefix. It
+// deliberately does not cap the walk: Suggest ranks the full candidate set
+// by frequency score before truncating to limit, and capping here instead
+// would mean which words even make it into the set depends on Go's
+// randomized map iteration order rather than on their score.
+func (n *suggestNode) collect(prefix string) []string {
+	var words []string
+	var walk func(node *suggestNode, built string)
+	walk = func(node *suggestNode, built string) {
+		if node.isWord {
+			words = append(words, built)
+		}
+		for r, child := range node.children {
+			walk(child, built+string(r))
+		}
+	}
+	walk(n, prefix)
+	return words
+}
+
+func (n *suggestNode) nodeAt(prefix string) *suggestNode {
+	cur := n
+	for _, r := range prefix {
+		next, ok := cur.children[r]
+		if !ok {
+			return nil
+		}
+		cur = next
+	}
+	return cur
+}
+
+// freqStat is the persisted usage record for one committed word.
+type freqStat struct {
+	Count      int       `json:"count"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// freqStore is the user-frequency store backing the recency-weighted part
+// of the suggestion ranking, persisted per-engine so each ibus engine
+// instance (there's one per profile/keyboard layout) keeps its own
+// history.
+type freqStore struct {
+	mu    sync.Mutex
+	path  string
+	stats map[string]freqStat
+}
+
+func loadFreqStore(engineName string) *freqStore {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("HOME")
+	}
+	path := filepath.Join(home, ".config", "ibus-bamboo", engineName, "freq.json")
+	fs := &freqStore{path: path, stats: map[string]freqStat{}}
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		_ = json.Unmarshal(data, &fs.stats)
+	}
+	return fs
+}
+
+func (fs *freqStore) bump(word string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	stat := fs.stats[word]
+	stat.Count++
+	stat.LastUsedAt = time.Now()
+	fs.stats[word] = stat
+	fs.save()
+}
+
+// score combines raw frequency with an exponential recency decay (half-life
+// of one day) so a word used heavily last month doesn't permanently outrank
+// one the user is typing right now.
+func (fs *freqStore) score(word string) float64 {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	stat, ok := fs.stats[word]
+	if !ok {
+		return 0
+	}
+	age := time.Since(stat.LastUsedAt).Hours() / 24
+	decay := math.Pow(0.5, age)
+	return float64(stat.Count) * decay
+}
+
+func (fs *freqStore) save() {
+	if err := os.MkdirAll(filepath.Dir(fs.path), 0700); err != nil {
+		return
+	}
+	data, err := json.Marshal(fs.stats)
+	if err != nil {
+		return
+	}
+	_ = ioutil.WriteFile(fs.path, data, 0600)
+}
+
+// SuggestionEngine ranks completions of the syllable/word currently being
+// composed, combining dictionary coverage with the user's own typing
+// history so frequently-used words surface first.
+type SuggestionEngine struct {
+	trie *suggestNode
+	freq *freqStore
+}
+
+// NewSuggestionEngine builds the prefix trie from the loaded Vietnamese
+// dictionary and opens the engine's persisted frequency store.
+func NewSuggestionEngine(dict map[string]bool, engineName string) *SuggestionEngine {
+	root := newSuggestNode()
+	for word := range dict {
+		root.insert(strings.ToLower(word))
+	}
+	return &SuggestionEngine{trie: root, freq: loadFreqStore(engineName)}
+}
+
+// Suggest returns up to limit completions of prefix, ranked by frequency
+// score (ties broken alphabetically).
+func (s *SuggestionEngine) Suggest(prefix string, limit int) []string {
+	prefix = strings.ToLower(prefix)
+	if prefix == "" {
+		return nil
+	}
+	node := s.trie.nodeAt(prefix)
+	if node == nil {
+		return nil
+	}
+	candidates := node.collect(prefix)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		si, sj := s.freq.score(candidates[i]), s.freq.score(candidates[j])
+		if si != sj {
+			return si > sj
+		}
+		return candidates[i] < candidates[j]
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
+
+// Accept records that the user committed word, so future rankings favor it.
+func (s *SuggestionEngine) Accept(word string) {
+	s.freq.bump(strings.ToLower(word))
+}
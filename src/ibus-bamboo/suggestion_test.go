@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSuggestNodeCollectIsComplete(t *testing.T) {
+	root := newSuggestNode()
+	words := []string{"bana", "banh", "bang", "ban", "bao"}
+	for _, w := range words {
+		root.insert(w)
+	}
+
+	node := root.nodeAt("ba")
+	if node == nil {
+		t.Fatalf("expected a node at prefix %q", "ba")
+	}
+
+	for i := 0; i < 20; i++ {
+		got := node.collect("ba")
+		if len(got) != len(words) {
+			t.Fatalf("run %d: collect returned %d words, want all %d: %v", i, len(got), len(words), got)
+		}
+	}
+}
+
+func TestSuggestEngineRanksByFrequency(t *testing.T) {
+	dict := map[string]bool{"banh": true, "bang": true, "ban": true}
+	engine := NewSuggestionEngine(dict, "test-engine")
+	engine.freq.path = filepath.Join(t.TempDir(), "freq.json")
+
+	engine.Accept("bang")
+	engine.Accept("bang")
+	engine.Accept("ban")
+
+	got := engine.Suggest("ba", 2)
+	if len(got) != 2 || got[0] != "bang" || got[1] != "ban" {
+		t.Fatalf("got %v, want [bang ban]", got)
+	}
+}
+
+func TestSuggestEngineEmptyPrefix(t *testing.T) {
+	engine := NewSuggestionEngine(map[string]bool{"banh": true}, "test-engine")
+	engine.freq.path = filepath.Join(t.TempDir(), "freq.json")
+	if got := engine.Suggest("", 5); got != nil {
+		t.Fatalf("expected nil for empty prefix, got %v", got)
+	}
+}
@@ -0,0 +1,17 @@
+package wm
+
+import "os"
+
+// detectSession follows the same heuristic every other Wayland-aware
+// application uses: WAYLAND_DISPLAY is authoritative when present, falling
+// back to XDG_SESSION_TYPE, and defaulting to X11 when neither is set
+// (XWayland, old Xorg sessions, etc.).
+func detectSession() sessionType {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return sessionWayland
+	}
+	if os.Getenv("XDG_SESSION_TYPE") == "wayland" {
+		return sessionWayland
+	}
+	return sessionX11
+}
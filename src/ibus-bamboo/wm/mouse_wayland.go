@@ -0,0 +1,99 @@
+package wm
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// evdevCapturer watches pointer button and motion events by parsing the
+// output of `libinput debug-events`. It only starts when libinput is on
+// PATH and at least one /dev/input/event* node can be opened by this
+// process (which usually needs the `input` group or a udev rule);
+// otherwise it captures nothing. Any pointer button press counts as a
+// click, not only the left button.
+type evdevCapturer struct {
+	onClick func()
+	onMove  func()
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stopCh chan struct{}
+}
+
+func newEvdevCapturer(onClick, onMove func()) *evdevCapturer {
+	return &evdevCapturer{onClick: onClick, onMove: onMove, stopCh: make(chan struct{})}
+}
+
+func (c *evdevCapturer) start() {
+	go c.run()
+}
+
+func (c *evdevCapturer) stop() {
+	close(c.stopCh)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cmd != nil && c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+}
+
+func (c *evdevCapturer) run() {
+	if !hasLibinput() {
+		return
+	}
+	cmd := exec.Command("libinput", "debug-events")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	c.cmd = cmd
+	c.mu.Unlock()
+	if err := cmd.Start(); err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		select {
+		case <-c.stopCh:
+			return
+		default:
+		}
+		line := scanner.Text()
+		switch {
+		// libinput debug-events prints one POINTER_BUTTON line for the press
+		// and another for the release of the same physical click; only the
+		// press edge fires onClick; the release is ignored, not treated as a
+		// move. (The X11 backend differs here: it reports a release as onMove.)
+		case strings.Contains(line, "POINTER_BUTTON") && strings.Contains(line, "pressed") && c.onClick != nil:
+			c.onClick()
+		case strings.Contains(line, "POINTER_BUTTON") && strings.Contains(line, "released"):
+			// swallow the release edge
+		case strings.Contains(line, "POINTER_MOTION") && c.onMove != nil:
+			c.onMove()
+		}
+	}
+}
+
+func hasLibinput() bool {
+	_, err := exec.LookPath("libinput")
+	if err != nil {
+		return false
+	}
+	matches, _ := filepath.Glob("/dev/input/event*")
+	if len(matches) == 0 {
+		return false
+	}
+	for _, m := range matches {
+		if f, err := os.Open(m); err == nil {
+			f.Close()
+			return true
+		}
+	}
+	return false
+}
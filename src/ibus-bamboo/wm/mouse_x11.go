@@ -0,0 +1,79 @@
+package wm
+
+/*
+#cgo LDFLAGS: -lX11 -lXtst
+#include <X11/Xlib.h>
+#include <X11/extensions/XTest.h>
+
+static int bamboo_x11_button_state(Display *d) {
+	Window root, child;
+	int rootX, rootY, winX, winY;
+	unsigned int mask;
+	if (!d) {
+		return 0;
+	}
+	XQueryPointer(d, DefaultRootWindow(d), &root, &child, &rootX, &rootY, &winX, &winY, &mask);
+	return (int)mask;
+}
+*/
+import "C"
+
+import "time"
+
+const pollInterval = 30 * time.Millisecond
+
+// buttonMask keeps only Button1Mask..Button5Mask (bits 0x100-0x1000) from
+// the XQueryPointer mask, since it also carries keyboard-modifier bits
+// (ShiftMask, ControlMask, ...). Without masking, pressing/releasing a
+// modifier with the mouse otherwise stationary looks like a mask change
+// and gets misreported as a move.
+const buttonMask C.int = 0x1F00
+
+// mouseCapturer polls XQueryPointer for button state changes. It's a poor
+// man's XRecord extension, but it's enough to detect clicks and drags
+// without pulling in a full X11 event-recording dependency.
+type mouseCapturer struct {
+	onClick func()
+	onMove  func()
+	stopCh  chan struct{}
+}
+
+func newMouseCapturer(onClick, onMove func()) *mouseCapturer {
+	return &mouseCapturer{onClick: onClick, onMove: onMove, stopCh: make(chan struct{})}
+}
+
+func (m *mouseCapturer) start() {
+	go m.run()
+}
+
+func (m *mouseCapturer) stop() {
+	close(m.stopCh)
+}
+
+func (m *mouseCapturer) run() {
+	d := C.XOpenDisplay(nil)
+	if d == nil {
+		return
+	}
+	defer C.XCloseDisplay(d)
+
+	var lastMask C.int
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+			mask := C.bamboo_x11_button_state(d) & buttonMask
+			if mask != lastMask {
+				if mask&0x0100 != 0 && m.onClick != nil { // Button1Mask
+					m.onClick()
+				} else if m.onMove != nil {
+					m.onMove()
+				}
+				lastMask = mask
+			}
+		}
+	}
+}
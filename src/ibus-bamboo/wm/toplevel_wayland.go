@@ -0,0 +1,191 @@
+package wm
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// toplevelTracker keeps a cached copy of the currently activated toplevel's
+// app_id. Kept separate from waylandWM so the tracking mechanism can change
+// (see the TODO on wlrToplevelClient below) without touching the
+// WindowManager implementation.
+type toplevelTracker struct {
+	mu     sync.RWMutex
+	appID  string
+	client *wlrToplevelClient
+}
+
+func newToplevelTracker() *toplevelTracker {
+	return &toplevelTracker{}
+}
+
+func (t *toplevelTracker) start() {
+	t.client = newWlrToplevelClient(func(appID string) {
+		t.mu.Lock()
+		t.appID = appID
+		t.mu.Unlock()
+	})
+	t.client.run()
+}
+
+func (t *toplevelTracker) activeAppID() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.appID
+}
+
+const toplevelPollInterval = 200 * time.Millisecond
+
+// wlrToplevelClient reports the focused toplevel's app_id.
+//
+// TODO: bind zwlr_foreign_toplevel_manager_v1 (or ext_foreign_toplevel_list_v1
+// on compositors that only ship the stable protocol) directly over the
+// wayland wire protocol, so this works on every compositor without shelling
+// out. That needs a generated protocol binding (e.g. via
+// github.com/rajveermalviya/go-wayland) which isn't vendored in this tree
+// yet; until then this polls each compositor's own IPC: Sway and Hyprland
+// via their own CLIs, GNOME Shell via org.gnome.Shell.Eval over D-Bus. KDE
+// Plasma still returns "" (same as before this package existed), since
+// Plasma exposes neither a CLI query nor an Eval-equivalent D-Bus call.
+type wlrToplevelClient struct {
+	onActivated func(appID string)
+	backend     func() (string, bool)
+}
+
+func newWlrToplevelClient(onActivated func(appID string)) *wlrToplevelClient {
+	return &wlrToplevelClient{onActivated: onActivated, backend: detectToplevelBackend()}
+}
+
+// run polls the detected backend on a goroutine for as long as the process
+// lives; there is currently no teardown since toplevelTracker is 1:1 with
+// the process-wide wm.Default() singleton.
+func (c *wlrToplevelClient) run() {
+	if c.backend == nil {
+		return
+	}
+	go c.poll()
+}
+
+func (c *wlrToplevelClient) poll() {
+	ticker := time.NewTicker(toplevelPollInterval)
+	defer ticker.Stop()
+	var last string
+	for range ticker.C {
+		appID, ok := c.backend()
+		if !ok || appID == last {
+			continue
+		}
+		last = appID
+		c.onActivated(appID)
+	}
+}
+
+// detectToplevelBackend picks the first available compositor IPC query, or
+// nil if none of the ones we know about are on PATH.
+func detectToplevelBackend() func() (string, bool) {
+	if _, err := exec.LookPath("swaymsg"); err == nil {
+		return swayActiveAppID
+	}
+	if _, err := exec.LookPath("hyprctl"); err == nil {
+		return hyprlandActiveAppID
+	}
+	if _, err := exec.LookPath("gdbus"); err == nil {
+		return gnomeShellActiveAppID
+	}
+	return nil
+}
+
+type swayNode struct {
+	Focused    bool       `json:"focused"`
+	AppID      string     `json:"app_id"`
+	WinProps   *swayClass `json:"window_properties"`
+	Nodes      []swayNode `json:"nodes"`
+	FloatNodes []swayNode `json:"floating_nodes"`
+}
+
+type swayClass struct {
+	Class string `json:"class"`
+}
+
+func swayActiveAppID() (string, bool) {
+	out, err := exec.Command("swaymsg", "-t", "get_tree").Output()
+	if err != nil {
+		return "", false
+	}
+	var root swayNode
+	if err := json.Unmarshal(out, &root); err != nil {
+		return "", false
+	}
+	if node, ok := findFocusedSwayNode(root); ok {
+		if node.AppID != "" {
+			return node.AppID, true
+		}
+		if node.WinProps != nil && node.WinProps.Class != "" {
+			return node.WinProps.Class, true
+		}
+	}
+	return "", false
+}
+
+func findFocusedSwayNode(n swayNode) (swayNode, bool) {
+	if n.Focused {
+		return n, true
+	}
+	for _, child := range append(n.Nodes, n.FloatNodes...) {
+		if found, ok := findFocusedSwayNode(child); ok {
+			return found, true
+		}
+	}
+	return swayNode{}, false
+}
+
+func hyprlandActiveAppID() (string, bool) {
+	out, err := exec.Command("hyprctl", "activewindow", "-j").Output()
+	if err != nil {
+		return "", false
+	}
+	var win struct {
+		Class string `json:"class"`
+	}
+	if err := json.Unmarshal(out, &win); err != nil {
+		return "", false
+	}
+	if win.Class == "" {
+		return "", false
+	}
+	return strings.ToLower(win.Class), true
+}
+
+// gnomeShellEvalJS is handed to org.gnome.Shell.Eval, which only runs it if
+// the user has enabled unsafe-mode once (e.g. via Looking Glass); outside
+// that it fails closed and gnomeShellActiveAppID reports no result, the
+// same as if the call wasn't available at all.
+const gnomeShellEvalJS = "global.display.focus_window ? global.display.focus_window.get_wm_class() : ''"
+
+func gnomeShellActiveAppID() (string, bool) {
+	out, err := exec.Command("gdbus", "call", "--session",
+		"--dest", "org.gnome.Shell",
+		"--object-path", "/org/gnome/Shell",
+		"--method", "org.gnome.Shell.Eval", gnomeShellEvalJS).Output()
+	if err != nil {
+		return "", false
+	}
+	// gdbus prints a tuple literal: (true, '"firefox"')
+	reply := strings.TrimSpace(string(out))
+	if !strings.HasPrefix(reply, "(true,") {
+		return "", false
+	}
+	start := strings.Index(reply, `'"`)
+	end := strings.LastIndex(reply, `"'`)
+	if start == -1 || end == -1 || end <= start {
+		return "", false
+	}
+	appID := reply[start+2 : end]
+	if appID == "" {
+		return "", false
+	}
+	return strings.ToLower(appID), true
+}
@@ -0,0 +1,71 @@
+package wm
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// waylandWM implements WindowManager on top of toplevelTracker (for focus
+// tracking, by polling the compositor's own IPC), wl-clipboard (for the
+// clipboard workaround) and ydotool/wtype (for synthetic key events), since
+// a sandboxed IME has no business injecting input itself under Wayland's
+// security model.
+type waylandWM struct {
+	mu       sync.Mutex
+	toplevel *toplevelTracker
+	capturer *evdevCapturer
+}
+
+func newWaylandWM() WindowManager {
+	w := &waylandWM{toplevel: newToplevelTracker()}
+	w.toplevel.start()
+	return w
+}
+
+// FocusedWindowClass returns the app_id of the focused toplevel as last
+// reported by toplevelTracker, which polls swaymsg, hyprctl or GNOME Shell's
+// Eval over D-Bus. It does not use the wlr-foreign-toplevel-management or
+// ext-foreign-toplevel-list protocols, and it returns "" on compositors none
+// of those queries reach, such as KDE Plasma.
+func (w *waylandWM) FocusedWindowClass() string {
+	return w.toplevel.activeAppID()
+}
+
+// SendShiftR re-injects Shift_R through the virtual keyboard protocol via
+// ydotool (falls back to wtype, which talks virtual-keyboard-unstable-v1
+// directly) since Wayland has no XTest equivalent available to clients.
+func (w *waylandWM) SendShiftR() {
+	// 54 is KEY_RIGHTSHIFT in linux/input-event-codes.h.
+	if err := exec.Command("ydotool", "key", "54:1", "54:0").Run(); err == nil {
+		return
+	}
+	_ = exec.Command("wtype", "-k", "Shift_R").Run()
+}
+
+// SendClipboard shells out to wl-copy, the de-facto wl-clipboard client.
+func (w *waylandWM) SendClipboard(text string) error {
+	cmd := exec.Command("wl-copy")
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+func (w *waylandWM) StartMouseCapturing(onClick func(), onMove func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.capturer != nil {
+		return
+	}
+	w.capturer = newEvdevCapturer(onClick, onMove)
+	w.capturer.start()
+}
+
+func (w *waylandWM) StopMouseCapturing() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.capturer == nil {
+		return
+	}
+	w.capturer.stop()
+	w.capturer = nil
+}
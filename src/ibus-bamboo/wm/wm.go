@@ -0,0 +1,87 @@
+/*
+ * Bamboo - A Vietnamese Input method editor
+ * Copyright (C) 2018 Luong Thanh Lam <ltlam93@gmail.com>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package wm abstracts the display-server-specific bits that ibus-bamboo
+// needs (focused window lookup, clipboard workaround, mouse capturing and
+// the Shift-key re-injection trick) so the engine behaves the same way
+// whether it is running under X11 or under a Wayland compositor.
+package wm
+
+import "sync"
+
+// WindowManager is implemented once per display server. IBusBambooEngine
+// only ever talks to this interface, never to the backends in x11.go or
+// wayland.go directly.
+type WindowManager interface {
+	// FocusedWindowClass returns the wm-class (or app_id, on Wayland) of
+	// the currently focused window, or "" if it cannot be determined.
+	FocusedWindowClass() string
+
+	// SendShiftR re-injects a Shift_R press+release. This is the
+	// surrounding-text workaround that convinces some toolkits to flush
+	// their preedit so ibus-bamboo can read it back via GetSurroundingText.
+	SendShiftR()
+
+	// SendClipboard pushes text onto the system clipboard, used as a
+	// fallback commit path for apps that don't support surrounding text.
+	SendClipboard(text string) error
+
+	// StartMouseCapturing begins listening for mouse button/move events
+	// and invokes onClick/onMove from that point on. Safe to call more
+	// than once; subsequent calls are no-ops.
+	StartMouseCapturing(onClick func(), onMove func())
+
+	// StopMouseCapturing stops the listener started by StartMouseCapturing.
+	StopMouseCapturing()
+}
+
+// sessionType mirrors the two session types ibus-bamboo cares about.
+type sessionType int
+
+const (
+	sessionX11 sessionType = iota
+	sessionWayland
+)
+
+var (
+	instanceOnce sync.Once
+	instance     WindowManager
+)
+
+// Default returns the process-wide WindowManager, auto-detecting and
+// constructing it on first use. GetBambooEngineCreator's factory runs once
+// per ibus input context, each on its own goroutine, so construction is
+// guarded by sync.Once: without it, two engines starting close together
+// could both observe a nil instance and race to construct one, leaking an
+// extra background toplevel-polling goroutine on Wayland for the loser.
+func Default() WindowManager {
+	instanceOnce.Do(func() {
+		instance = newForCurrentSession()
+	})
+	return instance
+}
+
+func newForCurrentSession() WindowManager {
+	switch detectSession() {
+	case sessionWayland:
+		return newWaylandWM()
+	default:
+		return newX11WM()
+	}
+}
@@ -0,0 +1,86 @@
+package wm
+
+/*
+#cgo LDFLAGS: -lX11 -lXtst
+#include <X11/Xlib.h>
+#include <X11/extensions/XTest.h>
+#include <X11/Xatom.h>
+#include <stdlib.h>
+
+static Display *bamboo_x11_open() {
+	return XOpenDisplay(NULL);
+}
+
+static void bamboo_x11_send_shift_r(Display *d) {
+	if (!d) {
+		return;
+	}
+	KeyCode code = XKeysymToKeycode(d, XK_Shift_R);
+	XTestFakeKeyEvent(d, code, True, 0);
+	XTestFakeKeyEvent(d, code, False, 0);
+	XFlush(d);
+}
+*/
+import "C"
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// x11WM is the original display-server backend: it shells out to
+// xdotool/xclip for anything that isn't worth a raw Xlib call, and uses
+// XTest directly for the Shift_R re-injection trick since that one needs
+// to be fast and synchronous.
+type x11WM struct {
+	mu       sync.Mutex
+	capturer *mouseCapturer
+}
+
+func newX11WM() WindowManager {
+	return &x11WM{}
+}
+
+func (w *x11WM) FocusedWindowClass() string {
+	out, err := exec.Command("xdotool", "getactivewindow", "getwindowclassname").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (w *x11WM) SendShiftR() {
+	d := C.bamboo_x11_open()
+	if d == nil {
+		return
+	}
+	C.bamboo_x11_send_shift_r(d)
+	C.XCloseDisplay(d)
+}
+
+func (w *x11WM) SendClipboard(text string) error {
+	cmd := exec.Command("xclip", "-selection", "clipboard")
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+func (w *x11WM) StartMouseCapturing(onClick func(), onMove func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.capturer != nil {
+		return
+	}
+	w.capturer = newMouseCapturer(onClick, onMove)
+	w.capturer.start()
+}
+
+func (w *x11WM) StopMouseCapturing() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.capturer == nil {
+		return
+	}
+	w.capturer.stop()
+	w.capturer = nil
+}